@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// changedPaths asks the VCS for the set of paths that have changed,
+// relative to repoRoot. When *sinceRev is set, it diffs since that
+// revision (for CI use on a PR); otherwise it diffs against the
+// current HEAD/working copy so local, uncommitted changes are picked
+// up too.
+//
+// In git mode, `git diff --name-only` only reports changes to files
+// git already tracks, so a brand-new, not-yet-`git add`ed
+// package.json/node_modules path is invisible to it. We union in
+// `git status --porcelain`'s untracked ("??") entries so those are
+// still picked up for the common pre-commit/freshly-scaffolded-project
+// case; `hg status` already reports untracked files by default.
+func changedPaths(repoRoot string) ([]string, error) {
+	var cmd *exec.Cmd
+	if *gitMode {
+		rev := "HEAD"
+		if *sinceRev != "" {
+			rev = *sinceRev + "..HEAD"
+		}
+		cmd = exec.Command("git", "diff", "--name-only", rev)
+	} else {
+		args := []string{"status"}
+		if *sinceRev != "" {
+			args = append(args, "--rev", *sinceRev)
+		}
+		cmd = exec.Command("hg", args...)
+	}
+	cmd.Dir = repoRoot
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if *hgMode {
+			// hg status lines look like "M path/to/file"
+			if parts := strings.SplitN(line, " ", 2); len(parts) == 2 {
+				line = parts[1]
+			}
+		}
+		paths = append(paths, line)
+	}
+
+	if *gitMode {
+		untracked, err := untrackedGitPaths(repoRoot)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, untracked...)
+	}
+
+	return paths, nil
+}
+
+// untrackedGitPaths returns the untracked file paths `git status
+// --porcelain` reports, so newly-scaffolded (not yet `git add`ed)
+// package.json/node_modules paths aren't missed by -changed/-since.
+func untrackedGitPaths(repoRoot string) ([]string, error) {
+	cmd := exec.Command("git", "status", "--porcelain", "--untracked-files=all")
+	cmd.Dir = repoRoot
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.HasPrefix(line, "?? ") {
+			continue
+		}
+		paths = append(paths, strings.TrimSpace(line[len("?? "):]))
+	}
+	return paths, nil
+}
+
+// changedProjectDirs maps a set of VCS-reported changed paths down to
+// the project directories (ones that may contain a package.json and
+// node_modules) that npmdiff should restrict its scan to.
+func changedProjectDirs(repoRoot string, paths []string) []string {
+	var (
+		seen = make(map[string]struct{})
+		dirs []string
+	)
+
+	addDir := func(dir string) {
+		full := filepath.Join(repoRoot, dir)
+		if _, ok := seen[full]; ok {
+			return
+		}
+		seen[full] = struct{}{}
+		dirs = append(dirs, full)
+	}
+
+	for _, p := range paths {
+		switch {
+		case strings.Contains(p, "node_modules/"):
+			addDir(p[:strings.Index(p, "node_modules/")])
+		case p == "node_modules" || strings.HasPrefix(p, "node_modules/"):
+			addDir(".")
+		case filepath.Base(p) == "package.json":
+			addDir(filepath.Dir(p))
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,55 @@
+package main
+
+import "fmt"
+
+// Diff is the structured result of diffing a single project directory's
+// package.json against its node_modules (and, depending on mode, its
+// transitive deps or lockfile). Reporters (text/json/sarif) all render
+// from this same shape.
+type Diff struct {
+	Path                 string   `json:"path"`
+	MissingInNodeModules []string `json:"missing_in_node_modules,omitempty"`
+	Extraneous           []string `json:"extraneous,omitempty"`
+	VersionMismatches    []string `json:"version_mismatches,omitempty"`
+	MissingPkgJSON       bool     `json:"missing_pkg_json,omitempty"`
+	MissingNodeModules   bool     `json:"missing_node_modules,omitempty"`
+
+	// Other holds diffs from modes (-recursive, -lock) that don't map
+	// cleanly onto the categories above.
+	Other []string `json:"other,omitempty"`
+
+	// MissingRanges records the package.json range declared for each
+	// name in MissingInNodeModules, so `npmdiff fix` knows what to
+	// install (e.g. "foo" -> "^1.2.0").
+	MissingRanges map[string]string `json:"-"`
+}
+
+// Empty reports whether there's nothing to tell the user about.
+func (d *Diff) Empty() bool {
+	return d == nil ||
+		(len(d.MissingInNodeModules) == 0 &&
+			len(d.Extraneous) == 0 &&
+			len(d.VersionMismatches) == 0 &&
+			len(d.Other) == 0 &&
+			!d.MissingPkgJSON &&
+			!d.MissingNodeModules)
+}
+
+// Messages renders d as the flat, human-readable strings the text
+// reporter has always printed.
+func (d *Diff) Messages() []string {
+	var msgs []string
+	for _, k := range d.MissingInNodeModules {
+		msgs = append(msgs,
+			fmt.Sprintf("%q is specified in 'package.json' but is not found locally", k),
+		)
+	}
+	msgs = append(msgs, d.VersionMismatches...)
+	for _, k := range d.Extraneous {
+		msgs = append(msgs,
+			fmt.Sprintf("%q found locally but is not specified in 'package.json'", k),
+		)
+	}
+	msgs = append(msgs, d.Other...)
+	return msgs
+}
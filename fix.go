@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// fix reconciles each project in allDiffs by shelling out to whichever
+// package manager it uses: installing missing deps, uninstalling
+// extraneous ones, and falling back to a clean reinstall when drift
+// also includes version mismatches. With dryRun it only prints the
+// commands it would have run.
+func fix(allDiffs map[string]*Diff, dryRun bool) {
+	for _, path := range sortedPaths(allDiffs) {
+		d := allDiffs[path]
+		mgr := detectPackageManager(path)
+
+		for _, cmd := range fixCommands(mgr, d) {
+			runFixCommand(path, cmd, dryRun)
+		}
+	}
+}
+
+// detectPackageManager looks for a lockfile in dir to decide which
+// package manager to drive; it defaults to npm.
+func detectPackageManager(dir string) string {
+	if _, err := os.Stat(filepath.Join(dir, "yarn.lock")); err == nil {
+		return "yarn"
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pnpm-lock.yaml")); err == nil {
+		return "pnpm"
+	}
+	return "npm"
+}
+
+// fixCommands builds the sequence of package manager invocations
+// (as argv slices) needed to reconcile d.
+func fixCommands(mgr string, d *Diff) [][]string {
+	var cmds [][]string
+
+	if len(d.MissingInNodeModules) > 0 {
+		args := []string{installVerb(mgr)}
+		for _, name := range d.MissingInNodeModules {
+			if rng, ok := d.MissingRanges[name]; ok && rng != "" {
+				args = append(args, fmt.Sprintf("%s@%s", name, rng))
+			} else {
+				args = append(args, name)
+			}
+		}
+		cmds = append(cmds, append([]string{mgr}, args...))
+	}
+
+	if len(d.Extraneous) > 0 {
+		args := append([]string{mgr, removeVerb(mgr)}, d.Extraneous...)
+		cmds = append(cmds, args)
+	}
+
+	if len(d.VersionMismatches) > 0 {
+		cmds = append(cmds, cleanInstallCommand(mgr))
+	}
+
+	return cmds
+}
+
+func installVerb(mgr string) string {
+	switch mgr {
+	case "yarn":
+		return "add"
+	case "pnpm":
+		return "add"
+	default:
+		return "install"
+	}
+}
+
+func removeVerb(mgr string) string {
+	switch mgr {
+	case "yarn":
+		return "remove"
+	case "pnpm":
+		return "remove"
+	default:
+		return "uninstall"
+	}
+}
+
+// cleanInstallCommand returns the command each manager uses to do a
+// clean, lockfile-exact reinstall.
+func cleanInstallCommand(mgr string) []string {
+	switch mgr {
+	case "yarn":
+		return []string{"yarn", "install", "--frozen-lockfile"}
+	case "pnpm":
+		return []string{"pnpm", "install", "--frozen-lockfile"}
+	default:
+		return []string{"npm", "ci"}
+	}
+}
+
+func runFixCommand(dir string, args []string, dryRun bool) {
+	if dryRun {
+		fmt.Printf("%s: %s\n", dir, strings.Join(args, " "))
+		return
+	}
+
+	fmt.Printf("%s: running %s\n", dir, strings.Join(args, " "))
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "npmdiff: fix command failed in %s, err: %s\n", dir, err)
+	}
+}
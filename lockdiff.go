@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ttacon/npmdiff/lockfile"
+)
+
+// diffLockfile diffs the node_modules tree rooted at base/node_modules
+// against whichever lockfile is present in base, reporting drift in
+// exact pinned versions and integrity hashes rather than the looser
+// package.json ranges diffDeps already checks.
+func diffLockfile(base string) []string {
+	lf, err := lockfile.Load(base)
+	if err != nil {
+		// no lockfile here, nothing to diff against
+		return nil
+	}
+
+	installed, err := getExistingDependencies(filepath.Join(base, "node_modules"))
+	if err != nil {
+		return nil
+	}
+
+	// npm 7+ no longer stamps each installed package.json with its
+	// integrity hash; node_modules/.package-lock.json is the one place
+	// that still records what was actually installed, so that's what
+	// we compare lock integrity against rather than false-flagging
+	// every package when it's absent.
+	installedIntegrity := map[string]string{}
+	if installedLock, err := lockfile.ParseNpmLock(filepath.Join(base, "node_modules", ".package-lock.json")); err == nil {
+		for name, pkg := range installedLock.Packages {
+			installedIntegrity[name] = pkg.Integrity
+		}
+	}
+
+	var diffs []string
+	for name, locked := range lf.Packages {
+		version, ok := installed[name]
+		if !ok {
+			diffs = append(diffs,
+				fmt.Sprintf("%q is pinned in %s but not found locally", name, lf.Path),
+			)
+			continue
+		}
+
+		if version != locked.Version {
+			diffs = append(diffs,
+				fmt.Sprintf(
+					"%q: installed %s, lock pins %s",
+					name, version, locked.Version,
+				),
+			)
+		}
+
+		if locked.Integrity != "" {
+			if got, ok := installedIntegrity[name]; ok && got != "" && got != locked.Integrity {
+				diffs = append(diffs,
+					fmt.Sprintf("%q: integrity %s does not match lock's %s", name, got, locked.Integrity),
+				)
+			}
+		}
+	}
+
+	for name := range installed {
+		if _, ok := lf.Packages[name]; !ok {
+			diffs = append(diffs,
+				fmt.Sprintf("%q found locally but is not in %s", name, lf.Path),
+			)
+		}
+	}
+
+	return diffs
+}
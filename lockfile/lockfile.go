@@ -0,0 +1,232 @@
+// Package lockfile parses the various npm/yarn lockfile formats into a
+// single common representation so npmdiff can compare a node_modules
+// tree against exact pinned versions instead of just the loose ranges
+// in package.json. Supporting a new format (e.g. pnpm-lock.yaml) should
+// just mean adding another Parse* function that returns a *Lockfile.
+package lockfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Package is a single resolved entry in a lockfile.
+type Package struct {
+	Name      string
+	Version   string
+	Resolved  string
+	Integrity string
+}
+
+// Lockfile is the common representation every format gets parsed into:
+// a flat map of package name to its pinned Package entry. Nested
+// (transitive) entries are flattened into the same map, keyed by name,
+// matching how npmdiff already treats node_modules as flat by name.
+type Lockfile struct {
+	Path     string
+	Packages map[string]Package
+}
+
+// Load finds and parses whichever lockfile is present in dir, checking
+// package-lock.json, npm-shrinkwrap.json and yarn.lock in that order.
+func Load(dir string) (*Lockfile, error) {
+	for _, name := range []string{"package-lock.json", "npm-shrinkwrap.json"} {
+		path := filepath.Join(dir, name)
+		if lf, err := ParseNpmLock(path); err == nil {
+			return lf, nil
+		}
+	}
+
+	if lf, err := ParseYarnLock(filepath.Join(dir, "yarn.lock")); err == nil {
+		return lf, nil
+	}
+
+	return nil, fmt.Errorf("lockfile: no lockfile found in %s", dir)
+}
+
+// npmLockEntry mirrors the subset of package-lock.json/npm-shrinkwrap.json
+// (lockfileVersion 1 style "dependencies" tree) that we care about.
+type npmLockEntry struct {
+	Version      string                  `json:"version"`
+	Resolved     string                  `json:"resolved"`
+	Integrity    string                  `json:"integrity"`
+	Dependencies map[string]npmLockEntry `json:"dependencies"`
+}
+
+// npmPackageEntry mirrors an entry in the flat lockfileVersion 2/3
+// "packages" map, keyed by install path (e.g. "node_modules/foo" or
+// "node_modules/foo/node_modules/bar").
+type npmPackageEntry struct {
+	Version   string `json:"version"`
+	Resolved  string `json:"resolved"`
+	Integrity string `json:"integrity"`
+}
+
+type npmLockFile struct {
+	Dependencies map[string]npmLockEntry    `json:"dependencies"`
+	Packages     map[string]npmPackageEntry `json:"packages"`
+}
+
+// ParseNpmLock parses a package-lock.json, npm-shrinkwrap.json, or
+// node_modules/.package-lock.json file. lockfileVersion 2/3's flat
+// "packages" map is preferred when present (npm 7+'s default); the
+// older lockfileVersion 1 "dependencies" tree is flattened as a
+// fallback for lockfiles that don't have one.
+func ParseNpmLock(path string) (*Lockfile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw npmLockFile
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	if len(raw.Packages) == 0 && len(raw.Dependencies) == 0 {
+		return nil, fmt.Errorf("lockfile: %s has neither a 'packages' nor a 'dependencies' tree", path)
+	}
+
+	lf := &Lockfile{Path: path, Packages: make(map[string]Package)}
+	for pkgPath, entry := range raw.Packages {
+		name := packageNameFromPath(pkgPath)
+		if name == "" {
+			// the "" entry describes the root project itself
+			continue
+		}
+		lf.Packages[name] = Package{
+			Name:      name,
+			Version:   entry.Version,
+			Resolved:  entry.Resolved,
+			Integrity: entry.Integrity,
+		}
+	}
+	flattenNpmLock(raw.Dependencies, lf.Packages)
+	return lf, nil
+}
+
+// packageNameFromPath extracts a package name from a lockfileVersion
+// 2/3 "packages" key, e.g. "node_modules/foo" -> "foo",
+// "node_modules/foo/node_modules/@scope/bar" -> "@scope/bar".
+func packageNameFromPath(pkgPath string) string {
+	const marker = "node_modules/"
+	idx := strings.LastIndex(pkgPath, marker)
+	if idx == -1 {
+		return ""
+	}
+	return pkgPath[idx+len(marker):]
+}
+
+func flattenNpmLock(deps map[string]npmLockEntry, out map[string]Package) {
+	for name, entry := range deps {
+		if _, ok := out[name]; ok {
+			// already present from the "packages" map, which is the
+			// more precise of the two when both are present
+			continue
+		}
+		out[name] = Package{
+			Name:      name,
+			Version:   entry.Version,
+			Resolved:  entry.Resolved,
+			Integrity: entry.Integrity,
+		}
+		if len(entry.Dependencies) > 0 {
+			flattenNpmLock(entry.Dependencies, out)
+		}
+	}
+}
+
+// ParseYarnLock parses a yarn.lock file. yarn.lock isn't JSON; it's a
+// sequence of blocks like:
+//
+//	foo@^1.0.0, foo@^1.2.0:
+//	  version "1.2.3"
+//	  resolved "https://registry.yarnpkg.com/foo/-/foo-1.2.3.tgz#abc123"
+//	  integrity sha512-...
+//
+// We only need the package name (from the header), version, resolved
+// and integrity fields out of each block.
+func ParseYarnLock(path string) (*Lockfile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lf := &Lockfile{Path: path, Packages: make(map[string]Package)}
+
+	var (
+		lines   = strings.Split(string(data), "\n")
+		name    string
+		pkg     Package
+		inBlock bool
+	)
+
+	flush := func() {
+		if inBlock && name != "" {
+			pkg.Name = name
+			lf.Packages[name] = pkg
+		}
+		inBlock = false
+		name = ""
+		pkg = Package{}
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			// new block header, e.g. `foo@^1.0.0, foo@^1.2.0:`
+			flush()
+			inBlock = true
+			name = yarnHeaderName(trimmed)
+			continue
+		}
+
+		if !inBlock {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "version "):
+			pkg.Version = unquote(strings.TrimPrefix(trimmed, "version "))
+		case strings.HasPrefix(trimmed, "resolved "):
+			pkg.Resolved = unquote(strings.TrimPrefix(trimmed, "resolved "))
+		case strings.HasPrefix(trimmed, "integrity "):
+			pkg.Integrity = strings.TrimPrefix(trimmed, "integrity ")
+		}
+	}
+	flush()
+
+	return lf, nil
+}
+
+// yarnHeaderName extracts the bare package name from a yarn.lock block
+// header such as `foo@^1.0.0, foo@^1.2.0:` or `@scope/foo@^1.0.0:`.
+func yarnHeaderName(header string) string {
+	header = strings.TrimSuffix(header, ":")
+	first := unquote(strings.TrimSpace(strings.Split(header, ",")[0]))
+
+	// scoped packages have a leading "@", so skip it when looking for the
+	// version-separating "@".
+	if strings.HasPrefix(first, "@") {
+		if i := strings.Index(first[1:], "@"); i != -1 {
+			return first[:i+1]
+		}
+		return first
+	}
+
+	if i := strings.Index(first, "@"); i != -1 {
+		return first[:i]
+	}
+	return first
+}
+
+func unquote(s string) string {
+	return strings.Trim(strings.TrimSpace(s), "\"")
+}
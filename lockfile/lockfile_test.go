@@ -0,0 +1,132 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTemp(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseNpmLockV3Packages(t *testing.T) {
+	path := writeTemp(t, "package-lock.json", `{
+		"lockfileVersion": 3,
+		"packages": {
+			"": {"name": "root"},
+			"node_modules/foo": {
+				"version": "1.2.3",
+				"resolved": "https://registry.npmjs.org/foo/-/foo-1.2.3.tgz",
+				"integrity": "sha512-foo"
+			},
+			"node_modules/@scope/bar": {
+				"version": "2.0.0",
+				"integrity": "sha512-bar"
+			},
+			"node_modules/foo/node_modules/baz": {
+				"version": "3.0.0"
+			}
+		}
+	}`)
+
+	lf, err := ParseNpmLock(path)
+	if err != nil {
+		t.Fatalf("ParseNpmLock: %v", err)
+	}
+
+	tests := map[string]string{
+		"foo":        "1.2.3",
+		"@scope/bar": "2.0.0",
+		"baz":        "3.0.0",
+	}
+	for name, version := range tests {
+		pkg, ok := lf.Packages[name]
+		if !ok {
+			t.Errorf("missing package %q", name)
+			continue
+		}
+		if pkg.Version != version {
+			t.Errorf("package %q version = %q, want %q", name, pkg.Version, version)
+		}
+	}
+	if got := lf.Packages["foo"].Integrity; got != "sha512-foo" {
+		t.Errorf("foo integrity = %q, want sha512-foo", got)
+	}
+}
+
+func TestParseNpmLockV1Dependencies(t *testing.T) {
+	path := writeTemp(t, "package-lock.json", `{
+		"lockfileVersion": 1,
+		"dependencies": {
+			"foo": {
+				"version": "1.2.3",
+				"integrity": "sha512-foo",
+				"dependencies": {
+					"bar": {"version": "4.5.6"}
+				}
+			}
+		}
+	}`)
+
+	lf, err := ParseNpmLock(path)
+	if err != nil {
+		t.Fatalf("ParseNpmLock: %v", err)
+	}
+	if got := lf.Packages["foo"].Version; got != "1.2.3" {
+		t.Errorf("foo version = %q, want 1.2.3", got)
+	}
+	if got := lf.Packages["bar"].Version; got != "4.5.6" {
+		t.Errorf("transitive bar version = %q, want 4.5.6", got)
+	}
+}
+
+func TestParseNpmLockMissingTree(t *testing.T) {
+	path := writeTemp(t, "package-lock.json", `{"lockfileVersion": 3}`)
+	if _, err := ParseNpmLock(path); err == nil {
+		t.Fatal("expected error for lockfile with neither packages nor dependencies")
+	}
+}
+
+func TestParseYarnLock(t *testing.T) {
+	path := writeTemp(t, "yarn.lock", `# THIS IS AN AUTOGENERATED FILE
+foo@^1.0.0, foo@^1.2.0:
+  version "1.2.3"
+  resolved "https://registry.yarnpkg.com/foo/-/foo-1.2.3.tgz#abc123"
+  integrity sha512-abc123==
+
+"@scope/bar@^2.0.0":
+  version "2.0.0"
+  resolved "https://registry.yarnpkg.com/@scope/bar/-/bar-2.0.0.tgz#def456"
+  integrity sha512-def456==
+`)
+
+	lf, err := ParseYarnLock(path)
+	if err != nil {
+		t.Fatalf("ParseYarnLock: %v", err)
+	}
+
+	foo, ok := lf.Packages["foo"]
+	if !ok {
+		t.Fatal("missing package foo")
+	}
+	if foo.Version != "1.2.3" {
+		t.Errorf("foo version = %q, want 1.2.3", foo.Version)
+	}
+	if foo.Integrity != "sha512-abc123==" {
+		t.Errorf("foo integrity = %q, want sha512-abc123==", foo.Integrity)
+	}
+
+	bar, ok := lf.Packages["@scope/bar"]
+	if !ok {
+		t.Fatal("missing scoped package @scope/bar")
+	}
+	if bar.Version != "2.0.0" {
+		t.Errorf("@scope/bar version = %q, want 2.0.0", bar.Version)
+	}
+}
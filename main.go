@@ -9,16 +9,31 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 )
 
 var (
-	hgMode  = flag.Bool("hg", false, "act as if we're in a mercurial repo")
-	gitMode = flag.Bool("git", false, "act as if we're in a git repo")
-	useExit = flag.Bool("exit-stat", false, "exit w/ non-zero status if diffs found")
+	hgMode      = flag.Bool("hg", false, "act as if we're in a mercurial repo")
+	gitMode     = flag.Bool("git", false, "act as if we're in a git repo")
+	useExit     = flag.Bool("exit-stat", false, "exit w/ non-zero status if diffs found")
+	recurseMode = flag.Bool("recursive", false, "also verify transitive dependencies inside nested node_modules")
+	lockMode    = flag.Bool("lock", false, "diff node_modules against package-lock.json/npm-shrinkwrap.json/yarn.lock instead of package.json ranges")
+	changedMode = flag.Bool("changed", false, "only scan package.json/node_modules paths changed according to the VCS")
+	sinceRev    = flag.String("since", "", "used with -changed: only consider changes since this revision")
+	format      = flag.String("format", "text", "output format: text, json, sarif")
+	failOn      = flag.String("fail-on", "missing,extra,mismatch,other", "comma separated categories (missing,extra,mismatch,other) that count toward -exit-stat's exit code; \"other\" covers -recursive/-lock findings that don't fit the first three")
+	dryRun      = flag.Bool("dry-run", false, "with the 'fix' subcommand, print the remediation commands instead of running them")
+	workers     = flag.Int("j", runtime.NumCPU(), "number of projects to diff concurrently")
 )
 
 func main() {
+	var fixMode bool
+	if len(os.Args) > 1 && os.Args[1] == "fix" {
+		fixMode = true
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
 	flag.Parse()
 
 	if !*hgMode && !*gitMode {
@@ -51,96 +66,78 @@ func main() {
 	}
 
 	var (
-		toTraverse = []string{outBuf.String()}
-		diffsFound = false
-		allDiffs   = make(map[string][]string)
-		nextDir    = func() string {
-			if len(toTraverse) == 0 {
-				return ""
-			}
-			var toReturn = toTraverse[0]
-			toTraverse = toTraverse[1:]
-			return toReturn
-		}
+		repoRoot   = strings.Trim(outBuf.String(), "\n")
+		toTraverse = []string{repoRoot}
 	)
 
-	for dir := nextDir(); dir != ""; dir = nextDir() {
-		dir = strings.Trim(dir, "\n")
-		fInfo, err := os.Open(dir)
-		if err != nil {
-			// swallow it?
-			continue
-		}
-
-		files, err := fInfo.Readdir(-1)
+	if *changedMode {
+		paths, err := changedPaths(repoRoot)
 		if err != nil {
-			// swallow it?
-			continue
-		}
-
-		var foundPkgJSON, foundNodeModules bool
-		for _, file := range files {
-			if file.Name() == "package.json" {
-				foundPkgJSON = true
-			} else if file.Name() == "node_modules" && file.IsDir() {
-				foundNodeModules = true
-			} else if file.IsDir() {
-				toTraverse = append(toTraverse, filepath.Join(dir, file.Name()))
-			}
-			if foundPkgJSON && foundNodeModules {
-				diffs := npmdiff(dir)
-				if len(diffs) > 0 {
-					diffsFound = true
-					allDiffs[dir] = append(allDiffs[dir], diffs...)
-				}
-			}
+			fmt.Fprintln(os.Stderr, "npmdiff: failed to determine changed paths, err: ", err)
+			return
 		}
+		toTraverse = changedProjectDirs(repoRoot, paths)
+	}
 
-		if foundPkgJSON && !foundNodeModules {
-			fmt.Fprintf(os.Stderr,
-				"found 'package.json' in %s, but no 'node_modules'\n",
-				dir,
-			)
-			diffsFound = true
-		} else if foundNodeModules && !foundPkgJSON {
-			fmt.Fprintf(os.Stderr,
-				"found 'node_modules' in %s, but no 'package.json'\n",
-				dir,
-			)
-			diffsFound = true
-		}
+	projectDirs, allDiffs := discoverProjectDirs(toTraverse)
+	for path, diff := range runDiffs(projectDirs, *workers) {
+		allDiffs[path] = diff
 	}
 
-	for k, v := range allDiffs {
-		fmt.Printf("differences found in %q:\n", k)
-		for i, diff := range v {
-			fmt.Printf("[%d] %s\n", i, diff)
-		}
+	if fixMode {
+		fix(allDiffs, *dryRun)
+		return
 	}
 
+	report(allDiffs)
+
 	// for use as script inside others
-	if diffsFound && *useExit {
+	if *useExit && anyDiffTriggersExit(allDiffs, parseFailOn(*failOn)) {
 		os.Exit(1)
 	}
 }
 
-func npmdiff(base string) []string {
-	devDeps, err := getDevDependencies(filepath.Join(base, "package.json"))
+// diffFor returns d if it's non-nil, or a fresh *Diff for path
+// otherwise, so callers can lazily initialize a project's entry.
+func diffFor(d *Diff, path string) *Diff {
+	if d != nil {
+		return d
+	}
+	return &Diff{Path: path}
+}
+
+func npmdiff(base string) *Diff {
+	diff := &Diff{Path: base}
+
+	pkgDeps, peerDeps, err := getAllDependencies(filepath.Join(base, "package.json"))
 	if err != nil {
 		// TODO(ttacon): return it
-		return nil
+		return diff
 	}
 
 	existingDeps, err := getExistingDependencies(filepath.Join(base, "node_modules"))
 	if err != nil {
 		// TODO(ttacon): return it
-		return nil
+		return diff
 	}
 
-	return diffDeps(devDeps, existingDeps)
+	diffDeps(diff, pkgDeps, peerDeps, existingDeps)
+	if *recurseMode {
+		diff.Other = append(diff.Other, diffTransitiveDeps(filepath.Join(base, "node_modules"))...)
+	}
+	if *lockMode {
+		diff.Other = append(diff.Other, diffLockfile(base)...)
+	}
+	return diff
 }
 
-func diffDeps(pkgDeps, localDeps map[string]string) []string {
+// diffDeps compares pkgDeps (name->declared range) against localDeps
+// (name->installed version), filling in diff's MissingInNodeModules,
+// Extraneous and VersionMismatches. peerDeps holds the names that came
+// from peerDependencies; npm doesn't guarantee those are installed, so
+// an absent peer isn't reported as missing (though an installed one
+// still has its version checked against the declared range).
+func diffDeps(diff *Diff, pkgDeps map[string]string, peerDeps map[string]struct{}, localDeps map[string]string) {
 	var sim = make(map[string]struct{})
 	for k, _ := range pkgDeps {
 		if _, ok := localDeps[k]; ok {
@@ -148,15 +145,23 @@ func diffDeps(pkgDeps, localDeps map[string]string) []string {
 		}
 	}
 
-	var diffs []string
 	// find pkgDeps diffs
-	for k, _ := range pkgDeps {
+	for k, rng := range pkgDeps {
 		if _, ok := sim[k]; !ok {
-			diffs = append(diffs,
-				fmt.Sprintf(
-					"%q is specified in 'package.json' but is not found locally",
-					k,
-				),
+			if _, isPeer := peerDeps[k]; isPeer {
+				continue
+			}
+			diff.MissingInNodeModules = append(diff.MissingInNodeModules, k)
+			if diff.MissingRanges == nil {
+				diff.MissingRanges = make(map[string]string)
+			}
+			diff.MissingRanges[k] = rng
+			continue
+		}
+
+		if installed := localDeps[k]; !satisfiesRange(installed, rng) {
+			diff.VersionMismatches = append(diff.VersionMismatches,
+				fmt.Sprintf("%q: installed %s does not satisfy %s", k, installed, rng),
 			)
 		}
 	}
@@ -164,16 +169,9 @@ func diffDeps(pkgDeps, localDeps map[string]string) []string {
 	// find localDeps diffs
 	for k, _ := range localDeps {
 		if _, ok := sim[k]; !ok {
-			diffs = append(diffs,
-				fmt.Sprintf(
-					"%q found locally but is not specified in 'package.json'",
-					k,
-				),
-			)
+			diff.Extraneous = append(diff.Extraneous, k)
 		}
 	}
-
-	return diffs
 }
 
 func getExistingDependencies(ndModLoc string) (map[string]string, error) {
@@ -182,22 +180,37 @@ func getExistingDependencies(ndModLoc string) (map[string]string, error) {
 		return nil, err
 	}
 
-	var existingDeps = make(map[string]string)
 	fInfos, err := file.Readdir(-1)
 	if err != nil {
 		return nil, err
 	}
+
+	var existingDeps = make(map[string]string)
 	for _, fInfo := range fInfos {
 		if !fInfo.IsDir() {
 			// weird, skip it
 			continue
 		}
 
+		if strings.HasPrefix(fInfo.Name(), "@") {
+			// scoped package dir, e.g. node_modules/@babel/core
+			scoped, err := getExistingDependencies(filepath.Join(ndModLoc, fInfo.Name()))
+			if err != nil {
+				continue
+			}
+			for name, version := range scoped {
+				existingDeps[fInfo.Name()+"/"+name] = version
+			}
+			continue
+		}
+
 		version, err := getPkgVersion(
 			filepath.Join(ndModLoc, fInfo.Name(), "package.json"),
 		)
 		if err != nil {
-			return nil, err
+			// no readable package.json here; skip it rather than
+			// aborting the whole scan
+			continue
 		}
 		existingDeps[fInfo.Name()] = version
 	}
@@ -212,23 +225,72 @@ func getPkgVersion(pkgLoc string) (string, error) {
 	return pkg.Version, nil
 }
 
+// pkgJSONCache memoizes parsed package.json files by path, so
+// concurrent workers sharing a workspace root (or npmdiff's own
+// -recursive/-lock passes) don't re-read and re-parse the same file.
+var pkgJSONCache sync.Map // path string -> *PackageJSON
+
 func getPkgJSON(pkgLoc string) (*PackageJSON, error) {
+	if cached, ok := pkgJSONCache.Load(pkgLoc); ok {
+		return cached.(*PackageJSON), nil
+	}
+
 	var pkg PackageJSON
 	dbytes, err := ioutil.ReadFile(pkgLoc)
 	if err != nil {
 		return nil, err
 	}
 
-	err = json.Unmarshal(dbytes, &pkg)
-	return &pkg, err
+	if err := json.Unmarshal(dbytes, &pkg); err != nil {
+		return nil, err
+	}
+
+	pkgJSONCache.Store(pkgLoc, &pkg)
+	return &pkg, nil
 }
 
-func getDevDependencies(pkgLoc string) (map[string]string, error) {
+// getAllDependencies merges dependencies, devDependencies,
+// optionalDependencies and peerDependencies into a single name->range
+// map, so npmdiff treats all of them as "declared" deps. It also
+// returns the set of names that are peerDependencies-only: unlike the
+// others, npm doesn't guarantee peers are installed, so callers
+// shouldn't treat an absent peer-only dep as "missing". A name that's
+// also listed under dependencies/devDependencies/optionalDependencies
+// (common for npm<7 compatibility, where the peer is also auto-installed
+// as a regular dependency) is still a hard requirement and excluded
+// from that set.
+func getAllDependencies(pkgLoc string) (map[string]string, map[string]struct{}, error) {
 	pkg, err := getPkgJSON(pkgLoc)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	var all = make(map[string]string)
+	for _, deps := range []map[string]string{
+		pkg.Dependencies,
+		pkg.DevDependencies,
+		pkg.OptionalDependencies,
+		pkg.PeerDependencies,
+	} {
+		for k, v := range deps {
+			all[k] = v
+		}
+	}
+
+	var peerOnly = make(map[string]struct{})
+	for k := range pkg.PeerDependencies {
+		peerOnly[k] = struct{}{}
+	}
+	for _, deps := range []map[string]string{
+		pkg.Dependencies,
+		pkg.DevDependencies,
+		pkg.OptionalDependencies,
+	} {
+		for k := range deps {
+			delete(peerOnly, k)
+		}
 	}
-	return pkg.DevDependencies, nil
+	return all, peerOnly, nil
 }
 
 type PackageJSON struct {
@@ -240,6 +302,9 @@ type PackageJSON struct {
 	Repository  map[string]string `json:"repository"`
 	Keywords    []string          `json:"keywords"`
 	//	Author          string            `json:"author"`
-	License         string            `json:"license"`
-	DevDependencies map[string]string `json:"devDependencies"`
+	License              string            `json:"license"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
 }
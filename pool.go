@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// discoverProjectDirs walks seed (and everything beneath it) looking
+// for project directories containing both a package.json and a
+// node_modules. It's the traversal "producer" half of the pipeline:
+// it doesn't do any diffing itself, just figures out what npmdiff
+// needs to run on, and collects the degenerate cases (package.json
+// with no node_modules, or vice versa) directly into a Diff map.
+func discoverProjectDirs(seed []string) ([]string, map[string]*Diff) {
+	var (
+		toTraverse  = append([]string{}, seed...)
+		preDiffs    = make(map[string]*Diff)
+		projectDirs []string
+		nextDir     = func() string {
+			if len(toTraverse) == 0 {
+				return ""
+			}
+			var toReturn = toTraverse[0]
+			toTraverse = toTraverse[1:]
+			return toReturn
+		}
+	)
+
+	for dir := nextDir(); dir != ""; dir = nextDir() {
+		dir = strings.Trim(dir, "\n")
+		fInfo, err := os.Open(dir)
+		if err != nil {
+			// swallow it?
+			continue
+		}
+
+		files, err := fInfo.Readdir(-1)
+		if err != nil {
+			// swallow it?
+			continue
+		}
+
+		var foundPkgJSON, foundNodeModules, enqueued bool
+		for _, file := range files {
+			if file.Name() == "package.json" {
+				foundPkgJSON = true
+			} else if file.Name() == "node_modules" && file.IsDir() {
+				foundNodeModules = true
+			} else if file.IsDir() {
+				toTraverse = append(toTraverse, filepath.Join(dir, file.Name()))
+			}
+			if foundPkgJSON && foundNodeModules && !enqueued {
+				projectDirs = append(projectDirs, dir)
+				enqueued = true
+			}
+		}
+
+		if foundPkgJSON && !foundNodeModules {
+			fmt.Fprintf(os.Stderr,
+				"found 'package.json' in %s, but no 'node_modules'\n",
+				dir,
+			)
+			preDiffs[dir] = diffFor(preDiffs[dir], dir)
+			preDiffs[dir].MissingNodeModules = true
+		} else if foundNodeModules && !foundPkgJSON {
+			fmt.Fprintf(os.Stderr,
+				"found 'node_modules' in %s, but no 'package.json'\n",
+				dir,
+			)
+			preDiffs[dir] = diffFor(preDiffs[dir], dir)
+			preDiffs[dir].MissingPkgJSON = true
+		}
+	}
+
+	return projectDirs, preDiffs
+}
+
+// runDiffs runs npmdiff over dirs using a pool of workers concurrent
+// workers, and merges their results into a single map. The map is
+// only ever written to by this one goroutine, so no mutex is needed.
+func runDiffs(dirs []string, workers int) map[string]*Diff {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		jobs    = make(chan string)
+		results = make(chan *Diff)
+		wg      sync.WaitGroup
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dir := range jobs {
+				if diff := npmdiff(dir); !diff.Empty() {
+					results <- diff
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for _, dir := range dirs {
+			jobs <- dir
+		}
+		close(jobs)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	merged := make(map[string]*Diff)
+	for diff := range results {
+		merged[diff.Path] = diff
+	}
+	return merged
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func buildBenchTree(b *testing.B, n int) string {
+	root := b.TempDir()
+	for i := 0; i < n; i++ {
+		projDir := filepath.Join(root, fmt.Sprintf("proj%d", i))
+		nmDir := filepath.Join(projDir, "node_modules", "dep")
+		if err := os.MkdirAll(nmDir, 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := ioutil.WriteFile(
+			filepath.Join(projDir, "package.json"),
+			[]byte(`{"dependencies":{"dep":"^1.0.0"}}`),
+			0644,
+		); err != nil {
+			b.Fatal(err)
+		}
+		if err := ioutil.WriteFile(
+			filepath.Join(nmDir, "package.json"),
+			[]byte(`{"version":"1.0.0"}`),
+			0644,
+		); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return root
+}
+
+// BenchmarkRunDiffs demonstrates how the worker pool scales with -j
+// against a synthetic tree of many small projects.
+func BenchmarkRunDiffs(b *testing.B) {
+	root := buildBenchTree(b, 50)
+	projectDirs, _ := discoverProjectDirs([]string{root})
+
+	for _, n := range []int{1, 4, 8} {
+		n := n
+		b.Run(fmt.Sprintf("workers=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				runDiffs(projectDirs, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// report renders allDiffs in whichever format the -format flag selected.
+func report(allDiffs map[string]*Diff) {
+	switch *format {
+	case "json":
+		reportJSON(allDiffs)
+	case "sarif":
+		reportSARIF(allDiffs)
+	default:
+		reportText(allDiffs)
+	}
+}
+
+// sortedPaths returns allDiffs' keys sorted, so output is deterministic
+// across runs (map iteration order isn't).
+func sortedPaths(allDiffs map[string]*Diff) []string {
+	paths := make([]string, 0, len(allDiffs))
+	for path := range allDiffs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func reportText(allDiffs map[string]*Diff) {
+	for _, path := range sortedPaths(allDiffs) {
+		fmt.Printf("differences found in %q:\n", path)
+		for i, msg := range allDiffs[path].Messages() {
+			fmt.Printf("[%d] %s\n", i, msg)
+		}
+	}
+}
+
+func reportJSON(allDiffs map[string]*Diff) {
+	out := make([]*Diff, 0, len(allDiffs))
+	for _, path := range sortedPaths(allDiffs) {
+		out = append(out, allDiffs[path])
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		fmt.Fprintln(os.Stderr, "npmdiff: failed to encode json output, err: ", err)
+	}
+}
+
+// sarifLog is a minimal SARIF 2.1.0 log, just enough of the schema to
+// get npmdiff findings into code-scanning dashboards.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+func reportSARIF(allDiffs map[string]*Diff) {
+	run := sarifRun{Tool: sarifTool{Driver: sarifDriver{Name: "npmdiff"}}}
+
+	addResult := func(path, ruleID, text string) {
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  ruleID,
+			Message: sarifMessage{Text: text},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: path},
+				},
+			}},
+		})
+	}
+
+	for _, path := range sortedPaths(allDiffs) {
+		d := allDiffs[path]
+		for _, k := range d.MissingInNodeModules {
+			addResult(path, "npmdiff/missing-dep",
+				fmt.Sprintf("%q is specified in 'package.json' but is not found locally", k))
+		}
+		for _, k := range d.Extraneous {
+			addResult(path, "npmdiff/extraneous-dep",
+				fmt.Sprintf("%q found locally but is not specified in 'package.json'", k))
+		}
+		for _, m := range d.VersionMismatches {
+			addResult(path, "npmdiff/version-mismatch", m)
+		}
+		if d.MissingPkgJSON {
+			addResult(path, "npmdiff/missing-package-json",
+				fmt.Sprintf("found 'node_modules' in %s, but no 'package.json'", path))
+		}
+		if d.MissingNodeModules {
+			addResult(path, "npmdiff/missing-node-modules",
+				fmt.Sprintf("found 'package.json' in %s, but no 'node_modules'", path))
+		}
+		for _, m := range d.Other {
+			addResult(path, "npmdiff/other", m)
+		}
+	}
+
+	log := sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(log); err != nil {
+		fmt.Fprintln(os.Stderr, "npmdiff: failed to encode sarif output, err: ", err)
+	}
+}
+
+// parseFailOn splits a comma separated -fail-on value into a set of
+// categories (missing, extra, mismatch).
+func parseFailOn(s string) map[string]struct{} {
+	categories := make(map[string]struct{})
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			categories[part] = struct{}{}
+		}
+	}
+	return categories
+}
+
+// anyDiffTriggersExit reports whether any diff falls into one of the
+// selected -fail-on categories.
+func anyDiffTriggersExit(allDiffs map[string]*Diff, categories map[string]struct{}) bool {
+	_, wantMissing := categories["missing"]
+	_, wantExtra := categories["extra"]
+	_, wantMismatch := categories["mismatch"]
+	_, wantOther := categories["other"]
+
+	for _, d := range allDiffs {
+		if wantMissing && (len(d.MissingInNodeModules) > 0 || d.MissingPkgJSON || d.MissingNodeModules) {
+			return true
+		}
+		if wantExtra && len(d.Extraneous) > 0 {
+			return true
+		}
+		if wantMismatch && len(d.VersionMismatches) > 0 {
+			return true
+		}
+		if wantOther && len(d.Other) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// diffTransitiveDeps walks every installed package under ndModLoc and
+// makes sure its own declared dependencies are resolvable, either from
+// its own nested node_modules or by walking up through ancestor
+// node_modules directories the way Node's require() resolution does.
+func diffTransitiveDeps(ndModLoc string) []string {
+	file, err := os.Open(ndModLoc)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	fInfos, err := file.Readdir(-1)
+	if err != nil {
+		return nil
+	}
+
+	var diffs []string
+	for _, fInfo := range fInfos {
+		if !fInfo.IsDir() {
+			continue
+		}
+
+		if strings.HasPrefix(fInfo.Name(), "@") {
+			// scoped package dir, e.g. node_modules/@babel: the actual
+			// packages live one level down, as @babel/core etc.
+			diffs = append(diffs, diffScopedTransitiveDeps(
+				filepath.Join(ndModLoc, fInfo.Name()), fInfo.Name(),
+			)...)
+			continue
+		}
+
+		diffs = append(diffs, diffPkgTransitiveDeps(
+			filepath.Join(ndModLoc, fInfo.Name()), fInfo.Name(),
+		)...)
+	}
+
+	return diffs
+}
+
+// diffScopedTransitiveDeps descends one level into a node_modules/@scope
+// directory and checks each scoped package it finds, e.g.
+// node_modules/@babel/core, prefixing diffs with "scope/name" rather
+// than treating the scope directory itself as a package.
+func diffScopedTransitiveDeps(scopeDir, scope string) []string {
+	file, err := os.Open(scopeDir)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	fInfos, err := file.Readdir(-1)
+	if err != nil {
+		return nil
+	}
+
+	var diffs []string
+	for _, fInfo := range fInfos {
+		if !fInfo.IsDir() {
+			continue
+		}
+		diffs = append(diffs, diffPkgTransitiveDeps(
+			filepath.Join(scopeDir, fInfo.Name()), scope+"/"+fInfo.Name(),
+		)...)
+	}
+	return diffs
+}
+
+// diffPkgTransitiveDeps checks that pkgDir's own declared dependencies
+// are resolvable and recurses into its nested node_modules, if any.
+// name is the package's full name (including any "scope/" prefix) used
+// only for reporting.
+func diffPkgTransitiveDeps(pkgDir, name string) []string {
+	deps, peerDeps, err := getAllDependencies(filepath.Join(pkgDir, "package.json"))
+	if err != nil {
+		// no package.json, or it's unreadable; nothing more we can check
+		return nil
+	}
+
+	var diffs []string
+	for dep := range deps {
+		if _, isPeer := peerDeps[dep]; isPeer {
+			// npm doesn't guarantee peers are installed; an unresolvable
+			// one isn't a resolution failure the way a regular dep is
+			continue
+		}
+		if !resolvePackage(pkgDir, dep) {
+			diffs = append(diffs,
+				fmt.Sprintf(
+					"%q requires %q, which could not be resolved from %s",
+					name, dep, pkgDir,
+				),
+			)
+		}
+	}
+
+	// recurse into this package's own nested node_modules, if any
+	diffs = append(diffs, diffTransitiveDeps(filepath.Join(pkgDir, "node_modules"))...)
+	return diffs
+}
+
+// resolvePackage mimics Node's module resolution for package lookups:
+// starting at fromDir, check fromDir/node_modules/<pkg>, then walk up
+// one directory at a time doing the same until a node_modules/<pkg> is
+// found or the filesystem root is reached.
+func resolvePackage(fromDir, pkg string) bool {
+	dir := fromDir
+	for {
+		candidate := filepath.Join(dir, "node_modules", pkg)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
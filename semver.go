@@ -0,0 +1,218 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// semVer is a minimal parsed semantic version, good enough to compare
+// the versions we find in node_modules/<pkg>/package.json against the
+// ranges declared in a parent package.json.
+type semVer struct {
+	major, minor, patch int
+}
+
+// parseSemVer parses strings like "1.2.3", "1.2", "1", dropping any
+// leading "v" and any prerelease/build metadata (e.g. "1.2.3-beta.1").
+func parseSemVer(v string) (semVer, bool) {
+	v = strings.TrimSpace(v)
+	v = strings.TrimPrefix(v, "v")
+	v = strings.TrimPrefix(v, "=")
+	if i := strings.IndexAny(v, "-+"); i != -1 {
+		v = v[:i]
+	}
+
+	parts := strings.Split(v, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return semVer{}, false
+	}
+
+	nums := [3]int{0, 0, 0}
+	for i, part := range parts {
+		if part == "" || part == "x" || part == "X" || part == "*" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return semVer{}, false
+		}
+		nums[i] = n
+	}
+
+	return semVer{major: nums[0], minor: nums[1], patch: nums[2]}, true
+}
+
+// compareSemVer returns -1, 0 or 1 depending on whether a is less than,
+// equal to, or greater than b.
+func compareSemVer(a, b semVer) int {
+	if a.major != b.major {
+		return compareInt(a.major, b.major)
+	}
+	if a.minor != b.minor {
+		return compareInt(a.minor, b.minor)
+	}
+	return compareInt(a.patch, b.patch)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// satisfiesRange reports whether the installed version satisfies the
+// given npm-style range. The range may be a set of OR'd clauses
+// separated by "||", each of which is a set of space separated
+// comparators that are AND'd together (e.g. ">=1.0.0 <2.0.0"). "^" and
+// "~" prefixes and hyphen ranges ("1.2.0 - 1.3.0") are also supported,
+// as well as "x"/"*" wildcards (e.g. "1.2.x", "*").
+func satisfiesRange(version, rng string) bool {
+	version = strings.TrimSpace(version)
+	rng = strings.TrimSpace(rng)
+	if rng == "" || rng == "*" || rng == "latest" {
+		return true
+	}
+
+	v, ok := parseSemVer(version)
+	if !ok {
+		// can't parse the installed version, so we can't say it's wrong
+		return true
+	}
+
+	for _, clause := range strings.Split(rng, "||") {
+		if satisfiesClause(v, strings.TrimSpace(clause)) {
+			return true
+		}
+	}
+	return false
+}
+
+// satisfiesClause handles a single AND'd set of comparators, plus the
+// hyphen-range shorthand.
+func satisfiesClause(v semVer, clause string) bool {
+	clause = strings.TrimSpace(clause)
+	if clause == "" {
+		return true
+	}
+
+	if strings.Contains(clause, " - ") {
+		bounds := strings.SplitN(clause, " - ", 2)
+		if len(bounds) == 2 {
+			return satisfiesComparator(v, ">="+strings.TrimSpace(bounds[0])) &&
+				satisfiesComparator(v, "<="+strings.TrimSpace(bounds[1]))
+		}
+	}
+
+	for _, comparator := range strings.Fields(clause) {
+		if !satisfiesComparator(v, comparator) {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfiesComparator handles a single comparator, e.g. "^1.2.0",
+// "~2.3.4", ">=1.0.0", or a bare "1.2.3"/"1.2.x" (treated as exact/wildcard).
+func satisfiesComparator(v semVer, comparator string) bool {
+	switch {
+	case strings.HasPrefix(comparator, "^"):
+		return satisfiesCaret(v, comparator[1:])
+	case strings.HasPrefix(comparator, "~"):
+		return satisfiesTilde(v, comparator[1:])
+	case strings.HasPrefix(comparator, ">="):
+		other, ok := parseSemVer(comparator[2:])
+		return ok && compareSemVer(v, other) >= 0
+	case strings.HasPrefix(comparator, "<="):
+		other, ok := parseSemVer(comparator[2:])
+		return ok && compareSemVer(v, other) <= 0
+	case strings.HasPrefix(comparator, ">"):
+		other, ok := parseSemVer(comparator[1:])
+		return ok && compareSemVer(v, other) > 0
+	case strings.HasPrefix(comparator, "<"):
+		other, ok := parseSemVer(comparator[1:])
+		return ok && compareSemVer(v, other) < 0
+	case strings.HasPrefix(comparator, "="):
+		return satisfiesWildcard(v, comparator[1:])
+	default:
+		return satisfiesWildcard(v, comparator)
+	}
+}
+
+// satisfiesWildcard handles bare/wildcard versions like "1.2.x", "1.x",
+// "*" and exact matches like "1.2.3".
+func satisfiesWildcard(v semVer, want string) bool {
+	want = strings.TrimSpace(want)
+	if want == "" || want == "x" || want == "X" || want == "*" {
+		return true
+	}
+
+	parts := strings.Split(want, ".")
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			return true
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return false
+		}
+		var have int
+		switch i {
+		case 0:
+			have = v.major
+		case 1:
+			have = v.minor
+		case 2:
+			have = v.patch
+		}
+		if have != n {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfiesCaret implements "^" ranges: allow changes that do not
+// modify the left-most non-zero digit.
+func satisfiesCaret(v semVer, want string) bool {
+	other, ok := parseSemVer(want)
+	if !ok {
+		return satisfiesWildcard(v, want)
+	}
+
+	if compareSemVer(v, other) < 0 {
+		return false
+	}
+
+	switch {
+	case other.major > 0:
+		return v.major == other.major
+	case other.minor > 0:
+		return v.major == 0 && v.minor == other.minor
+	default:
+		return v.major == 0 && v.minor == 0 && v.patch == other.patch
+	}
+}
+
+// satisfiesTilde implements "~" ranges: allow patch-level changes if a
+// minor version is specified (e.g. "~1.2.3" or "~1.2"), or minor-level
+// changes if only a major version is given (e.g. "~1" == ">=1.0.0 <2.0.0").
+func satisfiesTilde(v semVer, want string) bool {
+	other, ok := parseSemVer(want)
+	if !ok {
+		return satisfiesWildcard(v, want)
+	}
+
+	if compareSemVer(v, other) < 0 {
+		return false
+	}
+
+	if len(strings.Split(strings.TrimSpace(want), ".")) < 2 {
+		return v.major == other.major
+	}
+	return v.major == other.major && v.minor == other.minor
+}
@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+func TestSatisfiesRange(t *testing.T) {
+	tests := []struct {
+		version string
+		rng     string
+		want    bool
+	}{
+		{"1.2.3", "^1.2.0", true},
+		{"1.1.0", "^1.2.0", false},
+		{"2.0.0", "^1.2.0", false},
+		{"1.2.0", "~1.2.0", true},
+		{"1.2.9", "~1.2.0", true},
+		{"1.3.0", "~1.2.0", false},
+		{"1.9.0", "~1", true},
+		{"2.0.0", "~1", false},
+		{"1.5.0", ">=1.0.0 <2.0.0", true},
+		{"2.0.0", ">=1.0.0 <2.0.0", false},
+		{"1.5.0", "1.2.0 - 1.3.0 || 1.4.0 - 1.6.0", true},
+		{"1.2.x", "1.2.x", true},
+		{"1.2.5", "1.2.x", true},
+		{"1.3.0", "1.2.x", false},
+		{"9.9.9", "*", true},
+		{"1.0.0", "", true},
+	}
+	for _, tt := range tests {
+		if got := satisfiesRange(tt.version, tt.rng); got != tt.want {
+			t.Errorf("satisfiesRange(%q, %q) = %v, want %v", tt.version, tt.rng, got, tt.want)
+		}
+	}
+}
+
+func TestSatisfiesCaret(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+		ok      bool
+	}{
+		{"1.2.3", "1.2.0", true},
+		{"1.9.9", "1.2.0", true},
+		{"2.0.0", "1.2.0", false},
+		{"1.1.9", "1.2.0", false},
+		{"0.2.3", "0.2.0", true},
+		{"0.3.0", "0.2.0", false},
+		{"0.0.3", "0.0.3", true},
+		{"0.0.4", "0.0.3", false},
+	}
+	for _, tt := range tests {
+		v, ok := parseSemVer(tt.version)
+		if !ok {
+			t.Fatalf("parseSemVer(%q) failed", tt.version)
+		}
+		if got := satisfiesCaret(v, tt.want); got != tt.ok {
+			t.Errorf("satisfiesCaret(%q, %q) = %v, want %v", tt.version, tt.want, got, tt.ok)
+		}
+	}
+}
+
+func TestSatisfiesTilde(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+		ok      bool
+	}{
+		{"1.2.3", "1.2.0", true},
+		{"1.2.9", "1.2.0", true},
+		{"1.3.0", "1.2.0", false},
+		{"1.9.0", "1", true},
+		{"2.0.0", "1", false},
+		{"1.2.5", "1.2", true},
+		{"1.3.0", "1.2", false},
+	}
+	for _, tt := range tests {
+		v, ok := parseSemVer(tt.version)
+		if !ok {
+			t.Fatalf("parseSemVer(%q) failed", tt.version)
+		}
+		if got := satisfiesTilde(v, tt.want); got != tt.ok {
+			t.Errorf("satisfiesTilde(%q, %q) = %v, want %v", tt.version, tt.want, got, tt.ok)
+		}
+	}
+}